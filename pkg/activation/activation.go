@@ -0,0 +1,59 @@
+// Package activation records the outcome of action invocations so
+// they can be looked up after the fact via GET /activations, whether
+// the invocation that created them was blocking or not.
+package activation
+
+import (
+	"time"
+)
+
+// Status values an Activation can be in over its lifetime.
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Activation is a single record of an action invocation, independent
+// of how it's persisted.
+type Activation struct {
+	ID        string
+	Name      string
+	Namespace string
+	Start     time.Time
+	End       time.Time
+	Status    string
+	Result    interface{}
+	Logs      []string
+}
+
+// Store persists Activations so GetActivation/GetAllActivations can
+// read them back independently of the request (or goroutine) that
+// created them.
+type Store interface {
+	// Create records a new, typically still-running, Activation.
+	Create(a *Activation) error
+	// Update overwrites the Activation named a.ID with a's current
+	// fields, e.g. once a dispatch completes.
+	Update(a *Activation) error
+	// Get returns the Activation named id in namespace.
+	Get(namespace string, id string) (*Activation, error)
+	// List returns every Activation recorded in namespace.
+	List(namespace string) ([]*Activation, error)
+	// GC removes Activations whose End time is older than ttl. A zero
+	// End time (still running) is never collected. The memory store
+	// collects; the CRD store's GC is a no-op and removes nothing.
+	GC(ttl time.Duration) error
+}
+
+// clone returns a copy of a, including its Logs slice, so a Store
+// implementation can hand out or persist a snapshot without aliasing
+// the caller's Activation. A dispatch goroutine keeps mutating its own
+// *Activation (End, Status, Logs) after handing it to Store.Create, so
+// Store implementations must clone on every read and write rather than
+// keeping a shared pointer around.
+func (a *Activation) clone() *Activation {
+	out := *a
+	out.Logs = append([]string{}, a.Logs...)
+	return &out
+}
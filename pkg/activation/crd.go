@@ -0,0 +1,107 @@
+package activation
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/rest"
+)
+
+// crdStore persists Activations as "activations.kwsk.dev" custom
+// resources, so they survive a kwsk restart the same way Actions
+// (Configurations/Routes) already do.
+type crdStore struct {
+	client rest.Interface
+}
+
+// NewCRDStore returns a Store backed by the Activation CRD, built from
+// restConfig the same way the existing knativeClient is built from the
+// in-cluster or kubeconfig REST config.
+func NewCRDStore(restConfig *rest.Config) (Store, error) {
+	config := *restConfig
+	config.GroupVersion = &SchemeGroupVersion
+	config.APIPath = "/apis"
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(SchemeGroupVersion, &Resource{}, &ResourceList{})
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	config.NegotiatedSerializer = serializer.NewCodecFactory(scheme).WithoutConversion()
+
+	client, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, fmt.Errorf("building activation CRD client: %v", err)
+	}
+	log.Printf("warning: the activation CRD store does not honor --activation-ttl; GC is a no-op, rely on a cluster-level ResourceQuota/TTL controller instead")
+	return &crdStore{client: client}, nil
+}
+
+func (s *crdStore) Create(a *Activation) error {
+	resource, err := toResource(a)
+	if err != nil {
+		return err
+	}
+	return s.client.Post().
+		Namespace(a.Namespace).
+		Resource("activations").
+		Body(resource).
+		Do().
+		Error()
+}
+
+func (s *crdStore) Update(a *Activation) error {
+	resource, err := toResource(a)
+	if err != nil {
+		return err
+	}
+	return s.client.Put().
+		Namespace(a.Namespace).
+		Resource("activations").
+		Name(a.ID).
+		Body(resource).
+		Do().
+		Error()
+}
+
+func (s *crdStore) Get(namespace string, id string) (*Activation, error) {
+	result := &Resource{}
+	err := s.client.Get().
+		Namespace(namespace).
+		Resource("activations").
+		Name(id).
+		Do().
+		Into(result)
+	if err != nil {
+		return nil, err
+	}
+	return fromResource(result)
+}
+
+func (s *crdStore) List(namespace string) ([]*Activation, error) {
+	result := &ResourceList{}
+	err := s.client.Get().
+		Namespace(namespace).
+		Resource("activations").
+		Do().
+		Into(result)
+	if err != nil {
+		return nil, err
+	}
+	activations := make([]*Activation, len(result.Items))
+	for i := range result.Items {
+		activation, err := fromResource(&result.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		activations[i] = activation
+	}
+	return activations, nil
+}
+
+// GC is a no-op: the CRD store never deletes Activation resources, so
+// --activation-ttl has no effect on activations it persists.
+func (s *crdStore) GC(ttl time.Duration) error {
+	return nil
+}
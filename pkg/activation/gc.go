@@ -0,0 +1,44 @@
+package activation
+
+import (
+	"flag"
+	"log"
+	"time"
+)
+
+var ttl = flag.Duration("activation-ttl", 24*time.Hour, "how long to retain completed activations before garbage collecting them; --activation-ttl=0 disables GC")
+
+// minGCInterval is the shortest period StartGC will poll on, so a
+// small positive --activation-ttl (anything smaller than
+// minGCInterval*4) can't be fed straight into time.NewTicker, which
+// panics on a non-positive duration.
+const minGCInterval = time.Second
+
+// StartGC runs store.GC on a timer using the --activation-ttl flag
+// until stop is closed. Call it once per Store at startup. A
+// non-positive --activation-ttl disables GC entirely.
+func StartGC(store Store, stop <-chan struct{}) {
+	if *ttl <= 0 {
+		return
+	}
+
+	interval := *ttl / 4
+	if interval < minGCInterval {
+		interval = minGCInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := store.GC(*ttl); err != nil {
+					log.Printf("error garbage collecting activations: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
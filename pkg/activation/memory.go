@@ -0,0 +1,69 @@
+package activation
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// memoryStore is the default Store: activations live only as long as
+// the kwsk process does. It's always available, even when no CRD
+// client has been configured.
+type memoryStore struct {
+	mu          sync.RWMutex
+	activations map[string]*Activation
+}
+
+// NewMemoryStore returns a Store backed by an in-process map.
+func NewMemoryStore() Store {
+	return &memoryStore{activations: make(map[string]*Activation)}
+}
+
+func key(namespace string, id string) string {
+	return namespace + "/" + id
+}
+
+func (s *memoryStore) Create(a *Activation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activations[key(a.Namespace, a.ID)] = a.clone()
+	return nil
+}
+
+func (s *memoryStore) Update(a *Activation) error {
+	return s.Create(a)
+}
+
+func (s *memoryStore) Get(namespace string, id string) (*Activation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.activations[key(namespace, id)]
+	if !ok {
+		return nil, fmt.Errorf("activation %s not found in namespace %s", id, namespace)
+	}
+	return a.clone(), nil
+}
+
+func (s *memoryStore) List(namespace string) ([]*Activation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []*Activation
+	for _, a := range s.activations {
+		if a.Namespace == namespace {
+			result = append(result, a.clone())
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryStore) GC(ttl time.Duration) error {
+	cutoff := time.Now().Add(-ttl)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, a := range s.activations {
+		if !a.End.IsZero() && a.End.Before(cutoff) {
+			delete(s.activations, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package activation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreCreateGet(t *testing.T) {
+	store := NewMemoryStore()
+	a := &Activation{ID: "a1", Namespace: "default", Status: StatusRunning}
+	if err := store.Create(a); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := store.Get("default", "a1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.ID != a.ID || got.Status != a.Status {
+		t.Errorf("Get() = %+v, want ID=%q Status=%q", got, a.ID, a.Status)
+	}
+}
+
+func TestMemoryStoreGetNotFound(t *testing.T) {
+	store := NewMemoryStore()
+	if _, err := store.Get("default", "missing"); err == nil {
+		t.Errorf("Get() on missing activation should return an error")
+	}
+}
+
+func TestMemoryStoreCreateDoesNotAliasCaller(t *testing.T) {
+	store := NewMemoryStore()
+	a := &Activation{ID: "a1", Namespace: "default", Status: StatusRunning, Logs: []string{"first"}}
+	if err := store.Create(a); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	a.Status = StatusSuccess
+	a.Logs = append(a.Logs, "second")
+
+	got, err := store.Get("default", "a1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Status != StatusRunning {
+		t.Errorf("stored Status = %q, want %q (mutating caller's Activation after Create should not affect the store)", got.Status, StatusRunning)
+	}
+	if len(got.Logs) != 1 {
+		t.Errorf("stored Logs = %v, want len 1 (mutating caller's Logs slice after Create should not affect the store)", got.Logs)
+	}
+}
+
+func TestMemoryStoreUpdate(t *testing.T) {
+	store := NewMemoryStore()
+	a := &Activation{ID: "a1", Namespace: "default", Status: StatusRunning}
+	if err := store.Create(a); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	a.Status = StatusSuccess
+	if err := store.Update(a); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	got, err := store.Get("default", "a1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Status != StatusSuccess {
+		t.Errorf("Status after Update() = %q, want %q", got.Status, StatusSuccess)
+	}
+}
+
+func TestMemoryStoreList(t *testing.T) {
+	store := NewMemoryStore()
+	for _, a := range []*Activation{
+		{ID: "a1", Namespace: "default"},
+		{ID: "a2", Namespace: "default"},
+		{ID: "a3", Namespace: "other"},
+	} {
+		if err := store.Create(a); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	list, err := store.List("default")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("List(\"default\") returned %d activations, want 2", len(list))
+	}
+}
+
+func TestMemoryStoreGCRemovesOldCompletedActivations(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+
+	old := &Activation{ID: "old", Namespace: "default", End: now.Add(-2 * time.Hour)}
+	recent := &Activation{ID: "recent", Namespace: "default", End: now}
+	running := &Activation{ID: "running", Namespace: "default"}
+
+	for _, a := range []*Activation{old, recent, running} {
+		if err := store.Create(a); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	if err := store.GC(time.Hour); err != nil {
+		t.Fatalf("GC() error = %v", err)
+	}
+
+	if _, err := store.Get("default", "old"); err == nil {
+		t.Errorf("GC() should have removed the old completed activation")
+	}
+	if _, err := store.Get("default", "recent"); err != nil {
+		t.Errorf("GC() should not have removed the recent activation: %v", err)
+	}
+	if _, err := store.Get("default", "running"); err != nil {
+		t.Errorf("GC() should never remove a still-running activation: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package activation
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the API group the Activation CRD is registered under.
+const GroupName = "kwsk.dev"
+
+// SchemeGroupVersion is the group/version the Activation CRD is served
+// at.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+// Resource is the CustomResource persisted for a single Activation.
+// It mirrors Activation, but in the shape client-go's REST client
+// needs to marshal/unmarshal it.
+type Resource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Name      string          `json:"name"`
+	Namespace string          `json:"namespace"`
+	Start     metav1.Time     `json:"start"`
+	End       metav1.Time     `json:"end,omitempty"`
+	Status    string          `json:"status"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Logs      []string        `json:"logs,omitempty"`
+}
+
+// ResourceList is a list of Activation CustomResources, as returned by
+// a List() call against the CRD.
+type ResourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Resource `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object, which the REST client
+// requires when decoding CRD responses.
+func (in *Resource) DeepCopyObject() runtime.Object {
+	out := *in
+	out.Logs = append([]string{}, in.Logs...)
+	return &out
+}
+
+// DeepCopyObject implements runtime.Object, which the REST client
+// requires when decoding CRD responses.
+func (in *ResourceList) DeepCopyObject() runtime.Object {
+	out := *in
+	out.Items = append([]Resource{}, in.Items...)
+	return &out
+}
+
+func toResource(a *Activation) (*Resource, error) {
+	r := &Resource{
+		ObjectMeta: metav1.ObjectMeta{Name: a.ID, Namespace: a.Namespace},
+		Name:       a.Name,
+		Namespace:  a.Namespace,
+		Start:      metav1.NewTime(a.Start),
+		Status:     a.Status,
+		Logs:       a.Logs,
+	}
+	if !a.End.IsZero() {
+		r.End = metav1.NewTime(a.End)
+	}
+	if a.Result != nil {
+		result, err := json.Marshal(a.Result)
+		if err != nil {
+			return nil, err
+		}
+		r.Result = result
+	}
+	return r, nil
+}
+
+func fromResource(r *Resource) (*Activation, error) {
+	a := &Activation{
+		ID:        r.ObjectMeta.Name,
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Start:     r.Start.Time,
+		End:       r.End.Time,
+		Status:    r.Status,
+		Logs:      r.Logs,
+	}
+	if len(r.Result) > 0 {
+		if err := json.Unmarshal(r.Result, &a.Result); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,145 @@
+// Package cache serves Action lookups from shared informers instead of
+// hitting the Kubernetes API server on every request, which otherwise
+// dominates invocation latency: invokeActionFunc alone did two
+// sequential Gets (Route, then Configuration) before every dispatch.
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	knative "github.com/knative/serving/pkg/client/clientset/versioned"
+	informers "github.com/knative/serving/pkg/client/informers/externalversions"
+	listers "github.com/knative/serving/pkg/client/listers/serving/v1alpha1"
+)
+
+// actionNameIndex indexes cached Configurations by namespace and their
+// kwsk_action_name annotation, so getActionByNameFunc can look a
+// Configuration up by the user-facing OpenWhisk action name in O(1)
+// even though that name and kwsk's sanitized Kubernetes name aren't
+// the same string.
+const actionNameIndex = "kwsk_action_name"
+
+// ActionCache is a read-through cache of the Configuration and Route
+// resources that back OpenWhisk actions. A cache miss is not an error:
+// callers are expected to fall back to a live Get against the API
+// server, the same way they did before this cache existed.
+type ActionCache struct {
+	configInformer cache.SharedIndexInformer
+	configLister   listers.ConfigurationLister
+	routeLister    listers.RouteLister
+	revisionLister listers.RevisionLister
+}
+
+// New starts shared informers for Configurations and Routes against
+// knativeClient, blocks until their initial list has synced, and
+// returns the resulting ActionCache. Informers (and the cache they
+// back) stop when stop is closed.
+func New(knativeClient *knative.Clientset, stop <-chan struct{}) (*ActionCache, error) {
+	factory := informers.NewSharedInformerFactory(knativeClient, 10*time.Minute)
+	configInformer := factory.Serving().V1alpha1().Configurations()
+	routeInformer := factory.Serving().V1alpha1().Routes()
+	revisionInformer := factory.Serving().V1alpha1().Revisions()
+
+	err := configInformer.Informer().AddIndexers(cache.Indexers{
+		actionNameIndex: indexByActionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("adding action name indexer: %v", err)
+	}
+
+	factory.Start(stop)
+	if !cache.WaitForCacheSync(stop, configInformer.Informer().HasSynced, routeInformer.Informer().HasSynced, revisionInformer.Informer().HasSynced) {
+		return nil, fmt.Errorf("timed out waiting for action cache to sync")
+	}
+
+	return &ActionCache{
+		configInformer: configInformer.Informer(),
+		configLister:   configInformer.Lister(),
+		routeLister:    routeInformer.Lister(),
+		revisionLister: revisionInformer.Lister(),
+	}, nil
+}
+
+func indexByActionName(obj interface{}) ([]string, error) {
+	config, ok := obj.(*v1alpha1.Configuration)
+	if !ok {
+		return nil, nil
+	}
+	name, ok := config.Annotations["kwsk_action_name"]
+	if !ok {
+		return nil, nil
+	}
+	return []string{config.Namespace + "/" + name}, nil
+}
+
+// Configuration returns the Configuration named configName in
+// namespace from the cache. ok is false on a cache miss.
+func (c *ActionCache) Configuration(namespace string, configName string) (*v1alpha1.Configuration, bool) {
+	config, err := c.configLister.Configurations(namespace).Get(configName)
+	if err != nil {
+		return nil, false
+	}
+	return config, true
+}
+
+// ConfigurationByActionName returns the Configuration whose
+// kwsk_action_name annotation is name, in namespace. ok is false on a
+// cache miss.
+func (c *ActionCache) ConfigurationByActionName(namespace string, name string) (*v1alpha1.Configuration, bool) {
+	objs, err := c.configInformer.GetIndexer().ByIndex(actionNameIndex, namespace+"/"+name)
+	if err != nil || len(objs) == 0 {
+		return nil, false
+	}
+	config, ok := objs[0].(*v1alpha1.Configuration)
+	return config, ok
+}
+
+// Route returns the Route named routeName in namespace from the
+// cache. ok is false on a cache miss.
+func (c *ActionCache) Route(namespace string, routeName string) (*v1alpha1.Route, bool) {
+	route, err := c.routeLister.Routes(namespace).Get(routeName)
+	if err != nil {
+		return nil, false
+	}
+	return route, true
+}
+
+// LatestReadyRevisionUID returns the UID of the Revision named by
+// namespace/configName's current Status.LatestReadyRevisionName. ok is
+// false if the Configuration isn't cached, has no ready Revision yet,
+// or that Revision isn't (yet) cached.
+func (c *ActionCache) LatestReadyRevisionUID(namespace string, configName string) (types.UID, bool) {
+	config, ok := c.Configuration(namespace, configName)
+	if !ok || config.Status.LatestReadyRevisionName == "" {
+		return "", false
+	}
+	revision, err := c.revisionLister.Revisions(namespace).Get(config.Status.LatestReadyRevisionName)
+	if err != nil {
+		return "", false
+	}
+	return revision.UID, true
+}
+
+// List returns every Configuration cached for namespace.
+func (c *ActionCache) List(namespace string) ([]*v1alpha1.Configuration, error) {
+	return c.configLister.Configurations(namespace).List(labels.Everything())
+}
+
+// InvalidateConfiguration removes configName from the cache
+// immediately, rather than waiting for the informer to observe the
+// delete event over its watch. deleteActionFunc calls this right after
+// a successful API delete so a subsequent read in the same request
+// (or a fast-following one) can't observe a deleted action as still
+// present.
+func (c *ActionCache) InvalidateConfiguration(namespace string, configName string) {
+	c.configInformer.GetStore().Delete(&v1alpha1.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: configName},
+	})
+}
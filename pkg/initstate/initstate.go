@@ -0,0 +1,100 @@
+// Package initstate tracks which action revisions have already been
+// initialized, so invokeActionFunc can skip the init request for them
+// instead of sending one on every single invocation and relying on the
+// runtime returning 403 Forbidden to silently discard the duplicate.
+package initstate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Mode selects how kwsk decides whether an action revision still needs
+// to be initialized before it's invoked.
+type Mode string
+
+const (
+	// ModeOncePerRevision tracks (namespace, configName, revisionUID,
+	// codeHash) tuples in memory and skips init once a tuple has
+	// succeeded. Best-effort: the tracker is per-process, so a new
+	// kwsk replica (or one that's restarted) re-inits each action the
+	// first time it invokes it.
+	ModeOncePerRevision Mode = "once-per-revision"
+
+	// ModePerPod is handled exactly like ModeOncePerRevision: the same
+	// in-memory, per-process tracking of (namespace, configName,
+	// revisionUID, codeHash) tuples. No readiness gate or sidecar hook
+	// is installed on the action's Pods, so init is not tracked per Pod.
+	ModePerPod Mode = "per-pod"
+
+	// ModeAlways is the original behavior: send an init request before
+	// every invocation and rely on the runtime's 403 response to
+	// discard the duplicate.
+	ModeAlways Mode = "always"
+)
+
+var initMode = flag.String("init-mode", string(ModeOncePerRevision), "how to avoid re-initializing an action on every invocation: once-per-revision, per-pod, or always")
+
+// ModeFromFlag returns the Mode selected by --init-mode, falling back
+// to ModeAlways for an unrecognized value so a typo degrades to the
+// safest (if slowest) behavior rather than silently skipping init.
+func ModeFromFlag() Mode {
+	switch Mode(*initMode) {
+	case ModeOncePerRevision:
+		return ModeOncePerRevision
+	case ModePerPod:
+		return ModePerPod
+	default:
+		return ModeAlways
+	}
+}
+
+// Key identifies one initialized action revision. CodeHash is included
+// alongside RevisionUID because the kwsk_action_code annotation can be
+// updated in place on an existing Configuration without necessarily
+// rolling a new Revision.
+type Key struct {
+	Namespace   string
+	ConfigName  string
+	RevisionUID types.UID
+	CodeHash    string
+}
+
+// CodeHash returns the hex-encoded sha256 of an action's code, for use
+// as the CodeHash field of a Key.
+func CodeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Tracker records which Keys have already been initialized. It's an
+// in-memory, best-effort record: losing it just means the next
+// invocation of an affected action re-inits, the same as ModeAlways
+// always does.
+type Tracker struct {
+	mu   sync.Mutex
+	seen map[Key]bool
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{seen: make(map[Key]bool)}
+}
+
+// Seen reports whether key has already been recorded as initialized.
+func (t *Tracker) Seen(key Key) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.seen[key]
+}
+
+// MarkInitialized records key as initialized.
+func (t *Tracker) MarkInitialized(key Key) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seen[key] = true
+}
@@ -0,0 +1,59 @@
+package initstate
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCodeHashIsStableAndDistinct(t *testing.T) {
+	a := CodeHash("console.log('hi')")
+	b := CodeHash("console.log('hi')")
+	c := CodeHash("console.log('bye')")
+
+	if a != b {
+		t.Errorf("CodeHash() of identical code differed: %q vs %q", a, b)
+	}
+	if a == c {
+		t.Errorf("CodeHash() of different code collided: %q", a)
+	}
+}
+
+func TestTrackerSeenDefaultsFalse(t *testing.T) {
+	tracker := NewTracker()
+	key := Key{Namespace: "default", ConfigName: "my-action", RevisionUID: types.UID("uid-1"), CodeHash: CodeHash("code")}
+
+	if tracker.Seen(key) {
+		t.Errorf("Seen() on a fresh Tracker should be false")
+	}
+}
+
+func TestTrackerMarkInitializedThenSeen(t *testing.T) {
+	tracker := NewTracker()
+	key := Key{Namespace: "default", ConfigName: "my-action", RevisionUID: types.UID("uid-1"), CodeHash: CodeHash("code")}
+
+	tracker.MarkInitialized(key)
+	if !tracker.Seen(key) {
+		t.Errorf("Seen() should be true after MarkInitialized()")
+	}
+}
+
+func TestTrackerDistinguishesCodeHash(t *testing.T) {
+	tracker := NewTracker()
+	base := Key{Namespace: "default", ConfigName: "my-action", RevisionUID: types.UID("uid-1")}
+	first := base
+	first.CodeHash = CodeHash("v1")
+	second := base
+	second.CodeHash = CodeHash("v2")
+
+	tracker.MarkInitialized(first)
+	if tracker.Seen(second) {
+		t.Errorf("Seen() should be false for a different CodeHash on the same revision, since in-place code updates must re-init")
+	}
+}
+
+func TestModeFromFlagDefault(t *testing.T) {
+	if got := ModeFromFlag(); got != ModeOncePerRevision {
+		t.Errorf("ModeFromFlag() = %q, want default %q", got, ModeOncePerRevision)
+	}
+}
@@ -0,0 +1,37 @@
+package kubeclient
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+)
+
+const servingGroup = "serving.knative.dev"
+
+var apiGroupSuffix = flag.String("api-group-suffix", "", "override the domain suffix of the serving.knative.dev API group, for multi-tenant installs that serve it under a different domain")
+
+// GroupSuffixMiddleware rewrites the serving.knative.dev group on
+// outgoing requests to serving.<suffix>, so a multi-tenant install can
+// run its own copy of the Knative Serving API under a domain it
+// controls without kwsk hardcoding knative.dev anywhere.
+func GroupSuffixMiddleware(suffix string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if suffix == "" {
+			return next
+		}
+		rewritten := "serving." + suffix
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.Contains(req.URL.Path, "/"+servingGroup+"/") {
+				req = req.Clone(req.Context())
+				req.URL.Path = strings.Replace(req.URL.Path, "/"+servingGroup+"/", "/"+rewritten+"/", 1)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// DefaultGroupSuffixMiddleware builds GroupSuffixMiddleware from the
+// --api-group-suffix flag.
+func DefaultGroupSuffixMiddleware() Middleware {
+	return GroupSuffixMiddleware(*apiGroupSuffix)
+}
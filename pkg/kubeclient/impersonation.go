@@ -0,0 +1,43 @@
+package kubeclient
+
+import (
+	"context"
+	"net/http"
+
+	models "github.com/projectodd/kwsk/models"
+)
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying principal, so
+// ImpersonationMiddleware can recover it when it builds the outgoing
+// Kubernetes API request for that same request's context.
+func ContextWithPrincipal(ctx context.Context, principal *models.Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the *models.Principal stashed by
+// ContextWithPrincipal, or nil if none was set.
+func PrincipalFromContext(ctx context.Context) *models.Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*models.Principal)
+	return principal
+}
+
+// ImpersonationMiddleware sets the Kubernetes impersonation headers
+// from the *models.Principal on the outgoing request's context, so
+// requests are made as the authenticated OpenWhisk user rather than
+// kwsk's own service account. kwsk doesn't authenticate requests yet,
+// so nothing populates the context today; wiring the middleware
+// through now means enforcing per-user RBAC is a context-populating
+// change away rather than a new interception point.
+func ImpersonationMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if principal := PrincipalFromContext(req.Context()); principal != nil && principal.Subject != "" {
+				req = req.Clone(req.Context())
+				req.Header.Set("Impersonate-User", principal.Subject)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
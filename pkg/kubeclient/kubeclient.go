@@ -0,0 +1,83 @@
+// Package kubeclient wraps construction of kwsk's Knative Clientset
+// with an ordered chain of transport middlewares, hooked into the REST
+// config's WrapTransport the way Pinniped chains its own kubeclient
+// middlewares. This gives every request and response a single
+// interception point for cross-cutting concerns (API group rewriting,
+// request logging, impersonation) instead of scattering them across
+// restapi's handlers.
+package kubeclient
+
+import (
+	"net/http"
+
+	"k8s.io/client-go/rest"
+
+	knative "github.com/knative/serving/pkg/client/clientset/versioned"
+)
+
+// Middleware wraps an http.RoundTripper with another, the same shape
+// client-go's rest.Config.WrapTransport expects.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+type options struct {
+	middlewares []Middleware
+}
+
+// Option configures New.
+type Option func(*options)
+
+// WithMiddleware appends middlewares to the chain New installs, in the
+// order given. Requests pass through them in that order; responses
+// pass back through in reverse.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// New builds a *knative.Clientset from restConfig whose transport runs
+// every request and response through the middlewares named by opts, in
+// addition to whatever WrapTransport restConfig already had set.
+func New(restConfig *rest.Config, opts ...Option) (*knative.Clientset, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	config := *restConfig
+	previousWrap := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if previousWrap != nil {
+			rt = previousWrap(rt)
+		}
+		for i := len(o.middlewares) - 1; i >= 0; i-- {
+			rt = o.middlewares[i](rt)
+		}
+		return rt
+	}
+
+	return knative.NewForConfig(&config)
+}
+
+// NewDefault builds a *knative.Clientset from restConfig with the
+// standard middleware stack kwsk expects every Knative API request to
+// go through: group suffix rewriting, request logging, then
+// impersonation. This is the constructor kwsk's server bootstrap
+// should call instead of knative.NewForConfig directly, so every
+// knativeClient handed to configureActions and friends carries the
+// same cross-cutting behavior.
+func NewDefault(restConfig *rest.Config) (*knative.Clientset, error) {
+	return New(restConfig,
+		WithMiddleware(
+			DefaultGroupSuffixMiddleware(),
+			LoggingMiddleware(),
+			ImpersonationMiddleware(),
+		),
+	)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,133 @@
+package kubeclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	models "github.com/projectodd/kwsk/models"
+)
+
+func TestGroupSuffixMiddlewareRewritesPath(t *testing.T) {
+	var gotPath string
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		gotPath = req.URL.Path
+		return httptest.NewRecorder().Result(), nil
+	})
+
+	rt := GroupSuffixMiddleware("example.com")(base)
+	req := httptest.NewRequest("GET", "http://unused/apis/serving.knative.dev/v1alpha1/configurations", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+
+	want := "/apis/serving.example.com/v1alpha1/configurations"
+	if gotPath != want {
+		t.Errorf("rewritten path = %q, want %q", gotPath, want)
+	}
+}
+
+func TestGroupSuffixMiddlewareNoSuffixIsNoop(t *testing.T) {
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return httptest.NewRecorder().Result(), nil
+	})
+
+	rt := GroupSuffixMiddleware("")(base)
+	// roundTripperFunc values aren't comparable with ==, so compare the
+	// underlying function pointers instead.
+	if reflect.ValueOf(rt).Pointer() != reflect.ValueOf(base).Pointer() {
+		t.Errorf("GroupSuffixMiddleware(\"\") should return next unchanged")
+	}
+}
+
+func TestLoggingMiddlewarePassesThroughResponse(t *testing.T) {
+	want := httptest.NewRecorder()
+	want.Code = http.StatusTeapot
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return want.Result(), nil
+	})
+
+	rt := LoggingMiddleware()(base)
+	req := httptest.NewRequest("GET", "http://unused/", nil)
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if res.StatusCode != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusTeapot)
+	}
+}
+
+func TestImpersonationMiddlewareSetsHeaderFromContext(t *testing.T) {
+	var gotHeader string
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		gotHeader = req.Header.Get("Impersonate-User")
+		return httptest.NewRecorder().Result(), nil
+	})
+
+	rt := ImpersonationMiddleware()(base)
+	subject := "alice"
+	ctx := ContextWithPrincipal(context.Background(), &models.Principal{Subject: subject})
+	req := httptest.NewRequest("GET", "http://unused/", nil).WithContext(ctx)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+
+	if gotHeader != subject {
+		t.Errorf("Impersonate-User header = %q, want %q", gotHeader, subject)
+	}
+}
+
+func TestImpersonationMiddlewareNoPrincipalIsNoop(t *testing.T) {
+	var gotHeader string
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		gotHeader = req.Header.Get("Impersonate-User")
+		return httptest.NewRecorder().Result(), nil
+	})
+
+	rt := ImpersonationMiddleware()(base)
+	req := httptest.NewRequest("GET", "http://unused/", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+
+	if gotHeader != "" {
+		t.Errorf("Impersonate-User header = %q, want empty", gotHeader)
+	}
+}
+
+func TestWithMiddlewareOrdersRequestsOutsideIn(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return httptest.NewRecorder().Result(), nil
+	})
+
+	o := &options{}
+	WithMiddleware(tag("first"), tag("second"))(o)
+
+	var rt http.RoundTripper = base
+	for i := len(o.middlewares) - 1; i >= 0; i-- {
+		rt = o.middlewares[i](rt)
+	}
+
+	req := httptest.NewRequest("GET", "http://unused/", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+
+	want := []string{"first", "second"}
+	if len(order) != len(want) || order[0] != want[0] || order[1] != want[1] {
+		t.Errorf("middleware ran in order %v, want %v", order, want)
+	}
+}
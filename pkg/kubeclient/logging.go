@@ -0,0 +1,26 @@
+package kubeclient
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// LoggingMiddleware logs every outgoing request and its outcome as a
+// single structured line, replacing the fmt.Printf debug calls the
+// restapi handlers used to scatter around each knativeClient call.
+func LoggingMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+			elapsed := time.Since(start)
+			if err != nil {
+				log.Printf("kubeclient: method=%s url=%s duration=%s error=%v", req.Method, req.URL, elapsed, err)
+				return res, err
+			}
+			log.Printf("kubeclient: method=%s url=%s duration=%s status=%d", req.Method, req.URL, elapsed, res.StatusCode)
+			return res, err
+		})
+	}
+}
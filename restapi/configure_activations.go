@@ -0,0 +1,59 @@
+package restapi
+
+import (
+	middleware "github.com/go-openapi/runtime/middleware"
+
+	models "github.com/projectodd/kwsk/models"
+	"github.com/projectodd/kwsk/restapi/operations"
+	"github.com/projectodd/kwsk/restapi/operations/activations"
+
+	"github.com/projectodd/kwsk/pkg/activation"
+)
+
+func configureActivations(api *operations.KwskAPI, store activation.Store) {
+	api.ActivationsGetActivationByIDHandler = activations.GetActivationByIDHandlerFunc(getActivationByIDFunc(store))
+
+	api.ActivationsGetAllActivationsHandler = activations.GetAllActivationsHandlerFunc(getAllActivationsFunc(store))
+}
+
+func activationToModel(a *activation.Activation) *models.Activation {
+	activationId := a.ID
+	name := a.Name
+	namespace := a.Namespace
+	return &models.Activation{
+		ActivationID: &activationId,
+		Name:         &name,
+		Namespace:    &namespace,
+		Response: &models.ActivationResult{
+			Result:  a.Result,
+			Success: a.Status == activation.StatusSuccess,
+		},
+		Logs: a.Logs,
+	}
+}
+
+func getActivationByIDFunc(store activation.Store) activations.GetActivationByIDHandlerFunc {
+	return func(params activations.GetActivationByIDParams, principal *models.Principal) middleware.Responder {
+		namespace := namespaceOrDefault(params.Namespace)
+		a, err := store.Get(namespace, params.ActivationID)
+		if err != nil {
+			return activations.NewGetActivationByIDNotFound().WithPayload(errorMessageFromErr(err))
+		}
+		return activations.NewGetActivationByIDOK().WithPayload(activationToModel(a))
+	}
+}
+
+func getAllActivationsFunc(store activation.Store) activations.GetAllActivationsHandlerFunc {
+	return func(params activations.GetAllActivationsParams, principal *models.Principal) middleware.Responder {
+		namespace := namespaceOrDefault(params.Namespace)
+		list, err := store.List(namespace)
+		if err != nil {
+			return activations.NewGetAllActivationsInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		payload := make([]*models.Activation, len(list))
+		for i, a := range list {
+			payload[i] = activationToModel(a)
+		}
+		return activations.NewGetAllActivationsOK().WithPayload(payload)
+	}
+}
@@ -5,36 +5,57 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	middleware "github.com/go-openapi/runtime/middleware"
+	uuid "github.com/google/uuid"
 
 	models "github.com/projectodd/kwsk/models"
+	"github.com/projectodd/kwsk/pkg/activation"
+	"github.com/projectodd/kwsk/pkg/cache"
+	"github.com/projectodd/kwsk/pkg/initstate"
+	"github.com/projectodd/kwsk/pkg/kubeclient"
 	"github.com/projectodd/kwsk/restapi/operations"
 	"github.com/projectodd/kwsk/restapi/operations/actions"
+	"github.com/projectodd/kwsk/runtimes"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
 
 	"github.com/knative/serving/pkg/apis/serving/v1alpha1"
 	knative "github.com/knative/serving/pkg/client/clientset/versioned"
 )
 
-func configureActions(api *operations.KwskAPI, knativeClient *knative.Clientset) {
-	api.ActionsDeleteActionHandler = actions.DeleteActionHandlerFunc(deleteActionFunc(knativeClient))
+// configureActions wires up the actions API using a Knative client
+// built from restConfig via kubeclient.NewDefault, so every Knative API
+// call these handlers make runs through kwsk's standard middleware
+// stack (group suffix rewriting, logging, impersonation) rather than
+// bypassing it with a bare knative.NewForConfig client.
+func configureActions(api *operations.KwskAPI, restConfig *rest.Config, activationStore activation.Store, actionCache *cache.ActionCache, initTracker *initstate.Tracker) error {
+	knativeClient, err := kubeclient.NewDefault(restConfig)
+	if err != nil {
+		return err
+	}
+
+	api.ActionsDeleteActionHandler = actions.DeleteActionHandlerFunc(deleteActionFunc(knativeClient, actionCache))
 
-	api.ActionsGetActionByNameHandler = actions.GetActionByNameHandlerFunc(getActionByNameFunc(knativeClient))
+	api.ActionsGetActionByNameHandler = actions.GetActionByNameHandlerFunc(getActionByNameFunc(knativeClient, actionCache))
 
-	api.ActionsGetAllActionsHandler = actions.GetAllActionsHandlerFunc(getAllActionsFunc(knativeClient))
+	api.ActionsGetAllActionsHandler = actions.GetAllActionsHandlerFunc(getAllActionsFunc(knativeClient, actionCache))
 
-	api.ActionsInvokeActionHandler = actions.InvokeActionHandlerFunc(invokeActionFunc(knativeClient))
+	api.ActionsInvokeActionHandler = actions.InvokeActionHandlerFunc(invokeActionFunc(knativeClient, activationStore, actionCache, initTracker))
 
-	api.ActionsUpdateActionHandler = actions.UpdateActionHandlerFunc(updateActionFunc(knativeClient))
+	api.ActionsUpdateActionHandler = actions.UpdateActionHandlerFunc(updateActionFunc(knativeClient, actionCache))
+
+	return nil
 }
 
-func deleteActionFunc(knativeClient *knative.Clientset) actions.DeleteActionHandlerFunc {
+func deleteActionFunc(knativeClient *knative.Clientset, actionCache *cache.ActionCache) actions.DeleteActionHandlerFunc {
 	return func(params actions.DeleteActionParams, principal *models.Principal) middleware.Responder {
 		configName := sanitizeActionName(params.ActionName)
 		namespace := namespaceOrDefault(params.Namespace)
@@ -49,6 +70,7 @@ func deleteActionFunc(knativeClient *knative.Clientset) actions.DeleteActionHand
 			}
 			return actions.NewDeleteActionInternalServerError().WithPayload(errorMessage)
 		}
+		actionCache.InvalidateConfiguration(namespace, configName)
 
 		err = knativeClient.ServingV1alpha1().Routes(namespace).Delete(configName, &metav1.DeleteOptions{})
 		if err != nil {
@@ -66,84 +88,151 @@ func deleteActionFunc(knativeClient *knative.Clientset) actions.DeleteActionHand
 	}
 }
 
-func updateActionFunc(knativeClient *knative.Clientset) actions.UpdateActionHandlerFunc {
-	return func(params actions.UpdateActionParams, principal *models.Principal) middleware.Responder {
-		name := params.ActionName
-		configName := sanitizeActionName(name)
-		namespace := namespaceOrDefault(params.Namespace)
-		var image string
-
-		annotations := make(map[string]string)
-		annotations["kwsk_action_name"] = name
-		annotations["kwsk_action_version"] = params.Action.Version
+// maxUpdateConflictRetries bounds the optimistic-concurrency retry loop
+// in upsertActionConfiguration.
+const maxUpdateConflictRetries = 5
+
+func actionAnnotations(name string, action *models.Action) map[string]string {
+	annotations := make(map[string]string)
+	annotations["kwsk_action_name"] = name
+	annotations["kwsk_action_version"] = action.Version
+	if action.Exec != nil {
+		annotations["kwsk_action_kind"] = action.Exec.Kind
+		annotations["kwsk_action_code"] = action.Exec.Code
+	}
+	return annotations
+}
 
-		if params.Action.Exec != nil {
-			image = params.Action.Exec.Image
-			annotations["kwsk_action_kind"] = params.Action.Exec.Kind
-			annotations["kwsk_action_code"] = params.Action.Exec.Code
-		}
+func imageForAction(action *models.Action, annotations map[string]string) string {
+	if action.Exec != nil && action.Exec.Image != "" {
+		return action.Exec.Image
+	}
+	return runtimes.ImageFor(annotations["kwsk_action_kind"])
+}
 
-		config := &v1alpha1.Configuration{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:        configName,
-				Namespace:   namespace,
-				Annotations: annotations,
-			},
-			Spec: v1alpha1.ConfigurationSpec{
-				RevisionTemplate: v1alpha1.RevisionTemplateSpec{
-					ObjectMeta: metav1.ObjectMeta{},
-					Spec:       v1alpha1.RevisionSpec{},
+func newActionConfiguration(namespace string, configName string, annotations map[string]string, image string) *v1alpha1.Configuration {
+	return &v1alpha1.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        configName,
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Spec: v1alpha1.ConfigurationSpec{
+			RevisionTemplate: v1alpha1.RevisionTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{},
+				Spec: v1alpha1.RevisionSpec{
+					Container: corev1.Container{
+						Image: image,
+					},
 				},
 			},
-		}
+		},
+	}
+}
 
-		if image == "" {
-			// TODO: Map the kind of the action to an image instead of
-			// just assuming everything is node8
-			image = "openwhisk/action-nodejs-v8"
+// upsertActionConfiguration creates configName if it doesn't already
+// exist, or otherwise retries an optimistic-concurrency update against
+// its current ResourceVersion -- the same updateState/origStateIsCurrent
+// pattern the Kubernetes apiserver's etcd3 store uses for every write
+// -- so two PUTs racing on the same action don't silently clobber each
+// other's annotations.
+func upsertActionConfiguration(knativeClient *knative.Clientset, actionCache *cache.ActionCache, namespace string, configName string, name string, action *models.Action) (*v1alpha1.Configuration, error) {
+	annotations := actionAnnotations(name, action)
+	image := imageForAction(action, annotations)
+
+	for attempt := 0; attempt < maxUpdateConflictRetries; attempt++ {
+		// Only the first attempt may read from the informer cache. A
+		// conflict means the cache's ResourceVersion is already stale
+		// by definition, so every retry after one must re-Get live
+		// from the API server or it just reapplies the same stale
+		// ResourceVersion and conflicts again.
+		var current *v1alpha1.Configuration
+		var cached bool
+		if attempt == 0 {
+			current, cached = actionCache.Configuration(namespace, configName)
 		}
-		container := corev1.Container{
-			Image: image,
+		if !cached {
+			var err error
+			current, err = knativeClient.ServingV1alpha1().Configurations(namespace).Get(configName, metav1.GetOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
+			if errors.IsNotFound(err) {
+				current = nil
+			}
 		}
-		config.Spec.RevisionTemplate.Spec.Container = container
 
-		dbg := fmt.Sprintf("Creating configuration %+v\n", config)
-		fmt.Printf("%.2000s\n", dbg)
-		_, err := knativeClient.ServingV1alpha1().Configurations(namespace).Create(config)
-		if err != nil {
-			msg := err.Error()
-			errorMessage := &models.ErrorMessage{
-				Error: &msg,
+		if current == nil {
+			config := newActionConfiguration(namespace, configName, annotations, image)
+			created, err := knativeClient.ServingV1alpha1().Configurations(namespace).Create(config)
+			if err == nil {
+				return created, nil
+			}
+			if !errors.IsAlreadyExists(err) {
+				return nil, err
 			}
-			fmt.Println("Error updating action: ", err)
-			return actions.NewUpdateActionInternalServerError().WithPayload(errorMessage)
+			continue // someone else created it first; retry as an update
 		}
 
-		route := &v1alpha1.Route{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      configName,
-				Namespace: namespace,
-			},
-			Spec: v1alpha1.RouteSpec{
-				Traffic: []v1alpha1.TrafficTarget{
-					v1alpha1.TrafficTarget{
-						ConfigurationName: configName,
-						Percent:           100,
-					},
+		updated := current.DeepCopy()
+		updated.Annotations = annotations
+		updated.Spec.RevisionTemplate.Spec.Container.Image = image
+
+		result, err := knativeClient.ServingV1alpha1().Configurations(namespace).Update(updated)
+		if err == nil {
+			return result, nil
+		}
+		if !errors.IsConflict(err) {
+			return nil, err
+		}
+		// origStateIsCurrent was false: something else updated this
+		// Configuration between our Get and our Update. Loop around,
+		// re-read its latest ResourceVersion, and reapply ours on top.
+	}
+
+	return nil, fmt.Errorf("giving up updating action %s after %d conflicting concurrent updates", name, maxUpdateConflictRetries)
+}
+
+func ensureActionRoute(knativeClient *knative.Clientset, namespace string, configName string) error {
+	route := &v1alpha1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configName,
+			Namespace: namespace,
+		},
+		Spec: v1alpha1.RouteSpec{
+			Traffic: []v1alpha1.TrafficTarget{
+				v1alpha1.TrafficTarget{
+					ConfigurationName: configName,
+					Percent:           100,
 				},
 			},
-		}
-		_, err = knativeClient.ServingV1alpha1().Routes(namespace).Create(route)
-		action, err := getActionByName(knativeClient, name, namespace)
+		},
+	}
+	_, err := knativeClient.ServingV1alpha1().Routes(namespace).Create(route)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func updateActionFunc(knativeClient *knative.Clientset, actionCache *cache.ActionCache) actions.UpdateActionHandlerFunc {
+	return func(params actions.UpdateActionParams, principal *models.Principal) middleware.Responder {
+		name := params.ActionName
+		configName := sanitizeActionName(name)
+		namespace := namespaceOrDefault(params.Namespace)
+
+		config, err := upsertActionConfiguration(knativeClient, actionCache, namespace, configName, name, params.Action)
 		if err != nil {
-			msg := err.Error()
-			errorMessage := &models.ErrorMessage{
-				Error: &msg,
-			}
-			fmt.Println("Error retrieving updated action: ", err)
-			return actions.NewUpdateActionInternalServerError().WithPayload(errorMessage)
+			log.Printf("error updating action: %v", err)
+			return actions.NewUpdateActionInternalServerError().WithPayload(errorMessageFromErr(err))
 		}
-		return actions.NewUpdateActionOK().WithPayload(action)
+
+		if err := ensureActionRoute(knativeClient, namespace, configName); err != nil {
+			log.Printf("error updating action route: %v", err)
+			return actions.NewUpdateActionInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		return actions.NewUpdateActionOK().WithPayload(configToAction(config))
 	}
 }
 
@@ -153,21 +242,36 @@ func configToAction(config *v1alpha1.Configuration) *models.Action {
 	kind := objectMeta.Annotations["kwsk_action_kind"]
 	version := objectMeta.Annotations["kwsk_action_version"]
 	code := objectMeta.Annotations["kwsk_action_code"]
+	image := config.Spec.RevisionTemplate.Spec.Container.Image
+	if image == "" {
+		// Actions created before a kind had a registered image won't
+		// have one recorded on the Container; fall back to the
+		// registry so the reported Action is never left blank.
+		image = runtimes.ImageFor(kind)
+	}
 	return &models.Action{
 		Name:      &name,
 		Namespace: &objectMeta.Namespace,
 		Version:   &version,
 		Exec: &models.ActionExec{
-			Image: config.Spec.RevisionTemplate.Spec.Container.Image,
+			Image: image,
 			Kind:  kind,
 			Code:  code,
 		},
 	}
 }
 
-func getActionByName(knativeClient *knative.Clientset, name string, namespace string) (*models.Action, error) {
+func getActionByName(knativeClient *knative.Clientset, actionCache *cache.ActionCache, name string, namespace string) (*models.Action, error) {
 	configName := sanitizeActionName(name)
 	namespace = namespaceOrDefault(namespace)
+
+	if config, ok := actionCache.ConfigurationByActionName(namespace, name); ok {
+		return configToAction(config), nil
+	}
+	if config, ok := actionCache.Configuration(namespace, configName); ok {
+		return configToAction(config), nil
+	}
+
 	config, err := knativeClient.ServingV1alpha1().Configurations(namespace).Get(configName, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
@@ -175,9 +279,9 @@ func getActionByName(knativeClient *knative.Clientset, name string, namespace st
 	return configToAction(config), nil
 }
 
-func getActionByNameFunc(knativeClient *knative.Clientset) actions.GetActionByNameHandlerFunc {
+func getActionByNameFunc(knativeClient *knative.Clientset, actionCache *cache.ActionCache) actions.GetActionByNameHandlerFunc {
 	return func(params actions.GetActionByNameParams, principal *models.Principal) middleware.Responder {
-		action, err := getActionByName(knativeClient, params.ActionName, params.Namespace)
+		action, err := getActionByName(knativeClient, actionCache, params.ActionName, params.Namespace)
 		if err != nil {
 			msg := err.Error()
 			errorMessage := &models.ErrorMessage{
@@ -192,49 +296,135 @@ func getActionByNameFunc(knativeClient *knative.Clientset) actions.GetActionByNa
 	}
 }
 
-func getAllActionsFunc(knativeClient *knative.Clientset) actions.GetAllActionsHandlerFunc {
+func getAllActionsFunc(knativeClient *knative.Clientset, actionCache *cache.ActionCache) actions.GetAllActionsHandlerFunc {
 	return func(params actions.GetAllActionsParams, principal *models.Principal) middleware.Responder {
 		namespace := namespaceOrDefault(params.Namespace)
-		configs, err := knativeClient.ServingV1alpha1().Configurations(namespace).List(metav1.ListOptions{})
+
+		configs, err := actionCache.List(namespace)
 		if err != nil {
-			msg := err.Error()
-			errorMessage := &models.ErrorMessage{
-				Error: &msg,
+			list, err := knativeClient.ServingV1alpha1().Configurations(namespace).List(metav1.ListOptions{})
+			if err != nil {
+				return actions.NewGetAllActionsInternalServerError().WithPayload(errorMessageFromErr(err))
+			}
+			configs = make([]*v1alpha1.Configuration, len(list.Items))
+			for i := range list.Items {
+				configs[i] = &list.Items[i]
 			}
-			return actions.NewGetAllActionsInternalServerError().WithPayload(errorMessage)
 		}
-		var payload = make([]*models.Action, len(configs.Items))
-		for i, config := range configs.Items {
-			payload[i] = configToAction(&config)
+
+		payload := make([]*models.Action, len(configs))
+		for i, config := range configs {
+			payload[i] = configToAction(config)
 		}
 		return actions.NewGetAllActionsOK().WithPayload(payload)
 	}
 }
 
-type ActionInitMessage struct {
-	Value ActionInitValue `json:"value,omitempty"`
+func getActionParameters(params actions.InvokeActionParams) interface{} {
+	if params.Payload == nil {
+		return map[string]string{}
+	}
+	return params.Payload
 }
 
-type ActionInitValue struct {
-	Main string `json:"main,omitempty"`
-	Code string `json:"code,omitempty"`
-}
+// resolveActionRouteAndConfig resolves actionName's Route and
+// Configuration from actionCache, falling back to a live Get on a
+// cache miss. It's shared by every caller that needs to dispatch an
+// invocation against an action by name: invokeActionFunc and the
+// Rule/Trigger CloudEvent subscriber both resolve the same two
+// objects before they can init or run anything.
+// resolveActionRouteAndConfig looks up the Route and Configuration for
+// actionName the same way getActionByName looks up a Configuration:
+// ConfigurationByActionName first (keyed by the unsanitized
+// kwsk_action_name annotation), then the sanitized object name, falling
+// back to a live Get only on a cache miss.
+func resolveActionRouteAndConfig(knativeClient *knative.Clientset, actionCache *cache.ActionCache, namespace string, actionName string) (*v1alpha1.Route, *v1alpha1.Configuration, error) {
+	configName := sanitizeActionName(actionName)
+
+	config, ok := actionCache.ConfigurationByActionName(namespace, actionName)
+	if !ok {
+		config, ok = actionCache.Configuration(namespace, configName)
+	}
+	if !ok {
+		var err error
+		config, err = knativeClient.ServingV1alpha1().Configurations(namespace).Get(configName, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-type ActionRunMessage struct {
-	Value interface{} `json:"value"`
+	route, ok := actionCache.Route(namespace, configName)
+	if !ok {
+		var err error
+		route, err = knativeClient.ServingV1alpha1().Routes(namespace).Get(configName, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return route, config, nil
 }
 
-func getActionParameters(params actions.InvokeActionParams) interface{} {
-	if params.Payload == nil {
-		return map[string]string{}
+// dispatchInvocation runs the full invoke protocol against an action
+// already resolved to route/config -- init (skipped if initTracker
+// already has this revision, per initstate.ModeFromFlag), then run --
+// and records the outcome on act via store.Update. It's the one place
+// that builds the run/init request bodies (via runtimes.BuildRunMessage
+// and runtimes.BuildInitMessage), so every
+// caller that invokes an action (direct invoke, and Rule-triggered
+// CloudEvent delivery) goes through the same protocol and produces an
+// activation record the same way.
+func dispatchInvocation(knativeClient *knative.Clientset, actionCache *cache.ActionCache, initTracker *initstate.Tracker, store activation.Store, namespace string, config *v1alpha1.Configuration, actionHost string, actionParams interface{}, act *activation.Activation) {
+	annotations := config.Annotations
+
+	istioHostAndPort := kwskFlags.Istio
+	if istioHostAndPort == "" {
+		panic("Istio host and port must be provided via --istio flag to invoke actions")
+	}
+
+	needsInit := true
+	var initKey initstate.Key
+	mode := initstate.ModeFromFlag()
+	if mode == initstate.ModeOncePerRevision || mode == initstate.ModePerPod {
+		// per-pod uses the same in-memory, once-per-revision tracking;
+		// no readiness gate or sidecar hook is installed on the
+		// action's Pods.
+		key, err := initTrackerKey(knativeClient, actionCache, namespace, config)
+		if err == nil {
+			initKey = key
+			needsInit = !initTracker.Seen(key)
+		}
+	}
+
+	var dispatchErr error
+	if needsInit {
+		var initialized bool
+		initialized, dispatchErr = initAction(istioHostAndPort, actionHost, annotations["kwsk_action_kind"], annotations["kwsk_action_code"])
+		if dispatchErr == nil && initialized && initKey != (initstate.Key{}) {
+			initTracker.MarkInitialized(initKey)
+		}
+	}
+	if dispatchErr == nil {
+		dispatchErr = runAction(istioHostAndPort, actionHost, annotations["kwsk_action_kind"], actionParams, act)
+	}
+
+	act.End = time.Now()
+	if dispatchErr != nil {
+		act.Status = activation.StatusFailure
+		act.Logs = append(act.Logs, dispatchErr.Error())
+	} else {
+		act.Status = activation.StatusSuccess
+	}
+	if updateErr := store.Update(act); updateErr != nil {
+		log.Printf("error updating activation: %v", updateErr)
 	}
-	return params.Payload
 }
 
-func invokeActionFunc(knativeClient *knative.Clientset) actions.InvokeActionHandlerFunc {
+func invokeActionFunc(knativeClient *knative.Clientset, store activation.Store, actionCache *cache.ActionCache, initTracker *initstate.Tracker) actions.InvokeActionHandlerFunc {
 	return func(params actions.InvokeActionParams, principal *models.Principal) middleware.Responder {
 		namespace := namespaceOrDefault(params.Namespace)
-		route, err := knativeClient.ServingV1alpha1().Routes(namespace).Get(params.ActionName, metav1.GetOptions{})
+
+		route, config, err := resolveActionRouteAndConfig(knativeClient, actionCache, namespace, params.ActionName)
 		if err != nil {
 			errorMessage := errorMessageFromErr(err)
 			if errors.IsNotFound(err) {
@@ -242,120 +432,132 @@ func invokeActionFunc(knativeClient *knative.Clientset) actions.InvokeActionHand
 			}
 			return actions.NewInvokeActionInternalServerError().WithPayload(errorMessage)
 		}
+		actionHost := route.Status.Domain
 
-		config, err := knativeClient.ServingV1alpha1().Configurations(namespace).Get(params.ActionName, metav1.GetOptions{})
-		if err != nil {
-			errorMessage := errorMessageFromErr(err)
-			if errors.IsNotFound(err) {
-				return actions.NewInvokeActionNotFound().WithPayload(errorMessage)
-			}
-			return actions.NewInvokeActionInternalServerError().WithPayload(errorMessage)
+		act := &activation.Activation{
+			ID:        uuid.New().String(),
+			Name:      config.Name,
+			Namespace: namespace,
+			Start:     time.Now(),
+			Status:    activation.StatusRunning,
+		}
+		if err := store.Create(act); err != nil {
+			return actions.NewInvokeActionInternalServerError().WithPayload(errorMessageFromErr(err))
 		}
-		annotations := config.Annotations
 
-		actionHost := route.Status.Domain
+		actionParams := getActionParameters(params)
+		dispatch := func() {
+			dispatchInvocation(knativeClient, actionCache, initTracker, store, namespace, config, actionHost, actionParams, act)
+		}
 
-		// If we're running in-cluster this needs to be an internal
-		// hostname. If we're running outside the cluster, this needs
-		// to be the exposed route and/or nodeport. For now, don't
-		// worry about magic and expect it to be explicitly configured
-		// via a flag.
-		//
-		// host := "istio-ingress.istio-system.svc.cluster.local"
-		istioHostAndPort := kwskFlags.Istio
-		if istioHostAndPort == "" {
-			panic("Istio host and port must be provided via --istio flag to invoke actions")
+		// OpenWhisk's default is non-blocking; kwsk's existing clients
+		// all assumed blocking, so default to blocking here too unless
+		// the caller explicitly asks otherwise.
+		blocking := params.Blocking == nil || *params.Blocking
+		if !blocking {
+			go dispatch()
+			activationId := act.ID
+			return actions.NewInvokeActionAccepted().WithPayload(&models.ActivationID{ActivationID: &activationId})
 		}
 
-		// TODO: Don't init the action every time it's invoked
-		errResponder := initAction(istioHostAndPort, actionHost, annotations["kwsk_action_code"])
-		if errResponder != nil {
-			return errResponder
+		dispatch()
+		if act.Status == activation.StatusFailure {
+			msg := strings.Join(act.Logs, "\n")
+			return actions.NewInvokeActionInternalServerError().WithPayload(&models.ErrorMessage{Error: &msg})
 		}
-		return runAction(istioHostAndPort, actionHost, config.Name, namespace, getActionParameters(params))
+		return actions.NewInvokeActionOK().WithPayload(activationToModel(act))
 	}
 }
 
-func initAction(istioHostAndPort string, actionHost string, actionCode string) middleware.Responder {
-	initBody := &ActionInitMessage{
-		Value: ActionInitValue{
-			Main: "main",
-			Code: actionCode,
-		},
-	}
+// initAction sends an init request for the action and reports whether
+// it actually succeeded (initialized == true, status 200), as opposed
+// to being silently discarded as a harmless duplicate (status 403).
+// Callers use initialized to decide whether to record the action in an
+// initstate.Tracker.
+func initAction(istioHostAndPort string, actionHost string, kind string, actionCode string) (bool, error) {
+	initBody := runtimes.BuildInitMessage(kind, actionCode)
 	resStatus, resBody, err := actionRequest(istioHostAndPort, actionHost, "init", initBody)
 	if err != nil {
-		return actions.NewInvokeActionInternalServerError().WithPayload(errorMessageFromErr(err))
+		return false, err
 	}
 
 	if resStatus == http.StatusForbidden {
 		// ignore, since this is expected when we try to initialze an
 		// action multiple times
-	} else if resStatus != http.StatusOK {
-		msg := fmt.Sprintf("Error initializating action. Status: %d, Message: %s\n", resStatus, resBody)
-		errorMessage := &models.ErrorMessage{
-			Error: &msg,
-		}
-		return actions.NewInvokeActionInternalServerError().WithPayload(errorMessage)
+		return false, nil
+	}
+	if resStatus != http.StatusOK {
+		return false, fmt.Errorf("Error initializating action. Status: %d, Message: %s", resStatus, resBody)
 	}
 
-	return nil
+	return true, nil
 }
 
-func runAction(istioHostAndPort string, actionHost string, name string, namespace string, params interface{}) middleware.Responder {
+// initTrackerKey builds the initstate.Key identifying config's current
+// revision and code, so invokeActionFunc can tell whether this
+// Configuration's latest ready Revision has already been initialized.
+// initTrackerKey resolves config's current revision UID from
+// actionCache, the same lister-backed cache getActionByName and the
+// other handlers already read from, so building the tracker key never
+// costs a live Kubernetes API call on the invoke hot path. It falls
+// back to a live Get only on a cache miss, e.g. just after a brand new
+// Configuration's first Revision becomes ready and the informer hasn't
+// observed it yet.
+func initTrackerKey(knativeClient *knative.Clientset, actionCache *cache.ActionCache, namespace string, config *v1alpha1.Configuration) (initstate.Key, error) {
+	revisionName := config.Status.LatestReadyRevisionName
+	if revisionName == "" {
+		return initstate.Key{}, fmt.Errorf("configuration %s/%s has no ready revision yet", namespace, config.Name)
+	}
 
-	runBody := &ActionRunMessage{
-		Value: params,
+	revisionUID, ok := actionCache.LatestReadyRevisionUID(namespace, config.Name)
+	if !ok {
+		revision, err := knativeClient.ServingV1alpha1().Revisions(namespace).Get(revisionName, metav1.GetOptions{})
+		if err != nil {
+			return initstate.Key{}, err
+		}
+		revisionUID = revision.UID
 	}
+
+	return initstate.Key{
+		Namespace:   namespace,
+		ConfigName:  config.Name,
+		RevisionUID: revisionUID,
+		CodeHash:    initstate.CodeHash(config.Annotations["kwsk_action_code"]),
+	}, nil
+}
+
+func runAction(istioHostAndPort string, actionHost string, kind string, params interface{}, act *activation.Activation) error {
+	runBody := runtimes.BuildRunMessage(kind, params)
 	resStatus, resBody, err := actionRequest(istioHostAndPort, actionHost, "run", runBody)
 	if err != nil {
-		return actions.NewInvokeActionInternalServerError().WithPayload(errorMessageFromErr(err))
+		return err
+	}
+	if len(resBody) > 0 {
+		act.Logs = append(act.Logs, string(resBody))
 	}
 
 	if resStatus != http.StatusOK {
-		msg := fmt.Sprintf("Error invoking action. Status: %d, Message: %s\n", resStatus, resBody)
-		errorMessage := &models.ErrorMessage{
-			Error: &msg,
-		}
-		return actions.NewInvokeActionInternalServerError().WithPayload(errorMessage)
+		return fmt.Errorf("Error invoking action. Status: %d, Message: %s", resStatus, resBody)
 	}
 
 	var resultJson interface{}
 	err = json.Unmarshal(resBody, &resultJson)
 	if err != nil {
-		msg := fmt.Sprintf("Action invocation result was not valid JSON. Result: %s\n", resStatus, resBody)
-		errorMessage := &models.ErrorMessage{
-			Error: &msg,
-		}
-		return actions.NewInvokeActionInternalServerError().WithPayload(errorMessage)
+		return fmt.Errorf("Action invocation result was not valid JSON. Result: %s", resBody)
 	}
-	activationResult := &models.ActivationResult{
-		Result:  resultJson,
-		Success: true,
-	}
-
-	activationId := "dummyactivationid"
-	logs := []string{}
-	activation := &models.Activation{
-		ActivationID: &activationId,
-		Name:         &name,
-		Namespace:    &namespace,
-		Response:     activationResult,
-		Logs:         logs,
-	}
-	fmt.Printf("Activation: %+v\n", activation)
-	return actions.NewInvokeActionOK().WithPayload(activation)
+	act.Result = resultJson
+	return nil
 }
 
 func actionRequest(istioHostAndPort string, actionHost string, path string, requestBody interface{}) (int, []byte, error) {
 	url := fmt.Sprintf("http://%s/%s", istioHostAndPort, path)
-	fmt.Printf("Sending POST to url %s with host %s\n", url, actionHost)
+	log.Printf("sending POST to url %s with host %s", url, actionHost)
 
 	body, err := json.Marshal(requestBody)
 	if err != nil {
 		return 500, nil, err
 	}
-	fmt.Printf("Request Body: %s\n", body)
+	log.Printf("request body: %s", body)
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
 	if err != nil {
@@ -372,7 +574,7 @@ func actionRequest(istioHostAndPort string, actionHost string, path string, requ
 
 	defer res.Body.Close()
 	resBody, _ := ioutil.ReadAll(res.Body)
-	fmt.Printf("Response Body: %s\n", string(resBody))
+	log.Printf("response body: %s", resBody)
 
 	return res.StatusCode, resBody, nil
 }
@@ -0,0 +1,304 @@
+package restapi
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	middleware "github.com/go-openapi/runtime/middleware"
+	uuid "github.com/google/uuid"
+
+	models "github.com/projectodd/kwsk/models"
+	"github.com/projectodd/kwsk/pkg/activation"
+	"github.com/projectodd/kwsk/pkg/cache"
+	"github.com/projectodd/kwsk/pkg/initstate"
+	"github.com/projectodd/kwsk/pkg/kubeclient"
+	"github.com/projectodd/kwsk/restapi/operations"
+	"github.com/projectodd/kwsk/restapi/operations/rules"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	eventingv1alpha1 "github.com/knative/eventing/pkg/apis/eventing/v1alpha1"
+	eventing "github.com/knative/eventing/pkg/client/clientset/versioned"
+
+	knative "github.com/knative/serving/pkg/client/clientset/versioned"
+)
+
+// ruleSubscriberHost is the DNS name kwsk's own HTTP server is
+// reachable at from inside the cluster. updateRuleFunc points a Rule's
+// Trigger at this host instead of the action's Route, so the CloudEvent
+// a Rule fires arrives at RuleSubscriberHandler and is translated into
+// a normal invocation (init, run, activation) rather than being
+// delivered straight to the action pod.
+var ruleSubscriberHost = flag.String("rule-subscriber-host", "", "the DNS name kwsk's own HTTP server is reachable at from inside the cluster, used as the Knative Eventing Subscriber for Rules")
+
+// configureRules wires up the rules API using a Knative client built
+// from restConfig via kubeclient.NewDefault, the same way
+// configureActions does, so Route lookups made on a Rule's behalf go
+// through kwsk's standard middleware stack.
+func configureRules(api *operations.KwskAPI, restConfig *rest.Config, eventingClient *eventing.Clientset) error {
+	knativeClient, err := kubeclient.NewDefault(restConfig)
+	if err != nil {
+		return err
+	}
+
+	api.RulesDeleteRuleHandler = rules.DeleteRuleHandlerFunc(deleteRuleFunc(eventingClient))
+
+	api.RulesGetRuleByNameHandler = rules.GetRuleByNameHandlerFunc(getRuleByNameFunc(eventingClient))
+
+	api.RulesGetAllRulesHandler = rules.GetAllRulesHandlerFunc(getAllRulesFunc(eventingClient))
+
+	api.RulesUpdateRuleHandler = rules.UpdateRuleHandlerFunc(updateRuleFunc(knativeClient, eventingClient))
+
+	return nil
+}
+
+// A Rule has no Knative resource of its own: it points an existing
+// Trigger's Subscriber at kwsk's own RuleSubscriberHandler, identified
+// by --rule-subscriber-host, rather than at the action's Route
+// directly. Knative delivers the Trigger's matching CloudEvents there,
+// and RuleSubscriberHandler is what actually knows how to translate
+// one into the invoke protocol (init, run, activation) that invoking
+// the action by name would produce. Deleting
+// a Rule clears the Subscriber, leaving the Trigger (and its Broker
+// filter) intact.
+func updateRuleFunc(knativeClient *knative.Clientset, eventingClient *eventing.Clientset) rules.UpdateRuleHandlerFunc {
+	return func(params rules.UpdateRuleParams, principal *models.Principal) middleware.Responder {
+		name := params.RuleName
+		namespace := namespaceOrDefault(params.Namespace)
+		triggerName := sanitizeActionName(params.Rule.Trigger)
+		actionName := sanitizeActionName(params.Rule.Action)
+
+		// Confirm the action exists before wiring the Rule up to it;
+		// the Route itself isn't needed any more since the Subscriber
+		// now points at kwsk, not the action directly.
+		_, err := knativeClient.ServingV1alpha1().Routes(namespace).Get(actionName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return rules.NewUpdateRuleNotFound().WithPayload(errorMessageFromErr(err))
+			}
+			return rules.NewUpdateRuleInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		if *ruleSubscriberHost == "" {
+			msg := "--rule-subscriber-host must be set before a Rule can be attached to a Trigger"
+			return rules.NewUpdateRuleInternalServerError().WithPayload(&models.ErrorMessage{Error: &msg})
+		}
+
+		trigger, err := eventingClient.EventingV1alpha1().Triggers(namespace).Get(triggerName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return rules.NewUpdateRuleNotFound().WithPayload(errorMessageFromErr(err))
+			}
+			return rules.NewUpdateRuleInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		trigger.Spec.Subscriber = eventingv1alpha1.SubscriberSpec{DNSName: *ruleSubscriberHost}
+		if trigger.Annotations == nil {
+			trigger.Annotations = map[string]string{}
+		}
+		trigger.Annotations["kwsk_rule_name"] = name
+		trigger.Annotations["kwsk_rule_action"] = params.Rule.Action
+
+		_, err = eventingClient.EventingV1alpha1().Triggers(namespace).Update(trigger)
+		if err != nil {
+			log.Printf("error creating rule: %v", err)
+			return rules.NewUpdateRuleInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		result, err := getRuleByName(eventingClient, name, triggerName, namespace)
+		if err != nil {
+			return rules.NewUpdateRuleInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		return rules.NewUpdateRuleOK().WithPayload(result)
+	}
+}
+
+func deleteRuleFunc(eventingClient *eventing.Clientset) rules.DeleteRuleHandlerFunc {
+	return func(params rules.DeleteRuleParams, principal *models.Principal) middleware.Responder {
+		namespace := namespaceOrDefault(params.Namespace)
+		trigger, err := findTriggerForRule(eventingClient, params.RuleName, namespace)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return rules.NewDeleteRuleNotFound().WithPayload(errorMessageFromErr(err))
+			}
+			return rules.NewDeleteRuleInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		trigger.Spec.Subscriber = eventingv1alpha1.SubscriberSpec{}
+		delete(trigger.Annotations, "kwsk_rule_name")
+		delete(trigger.Annotations, "kwsk_rule_action")
+		_, err = eventingClient.EventingV1alpha1().Triggers(namespace).Update(trigger)
+		if err != nil {
+			return rules.NewDeleteRuleInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		return rules.NewDeleteRuleOK()
+	}
+}
+
+func ruleToModel(name string, triggerName string, trigger *eventingv1alpha1.Trigger) *models.Rule {
+	return &models.Rule{
+		Name:      &name,
+		Namespace: &trigger.Namespace,
+		Trigger:   trigger.Annotations["kwsk_trigger_name"],
+		Action:    trigger.Annotations["kwsk_rule_action"],
+	}
+}
+
+// findTriggerForRule looks up the Trigger a Rule was attached to by the
+// kwsk_rule_name annotation updateRuleFunc stamped onto it.
+func findTriggerForRule(eventingClient *eventing.Clientset, name string, namespace string) (*eventingv1alpha1.Trigger, error) {
+	list, err := eventingClient.EventingV1alpha1().Triggers(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, trigger := range list.Items {
+		if trigger.Annotations["kwsk_rule_name"] == name {
+			return &trigger, nil
+		}
+	}
+	return nil, errors.NewNotFound(eventingv1alpha1.Resource("triggers"), name)
+}
+
+func getRuleByName(eventingClient *eventing.Clientset, name string, triggerName string, namespace string) (*models.Rule, error) {
+	namespace = namespaceOrDefault(namespace)
+	trigger, err := eventingClient.EventingV1alpha1().Triggers(namespace).Get(triggerName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return ruleToModel(name, triggerName, trigger), nil
+}
+
+func getRuleByNameFunc(eventingClient *eventing.Clientset) rules.GetRuleByNameHandlerFunc {
+	return func(params rules.GetRuleByNameParams, principal *models.Principal) middleware.Responder {
+		namespace := namespaceOrDefault(params.Namespace)
+		trigger, err := findTriggerForRule(eventingClient, params.RuleName, namespace)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return rules.NewGetRuleByNameNotFound().WithPayload(errorMessageFromErr(err))
+			}
+			return rules.NewGetRuleByNameInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		return rules.NewGetRuleByNameOK().WithPayload(ruleToModel(params.RuleName, trigger.Name, trigger))
+	}
+}
+
+func getAllRulesFunc(eventingClient *eventing.Clientset) rules.GetAllRulesHandlerFunc {
+	return func(params rules.GetAllRulesParams, principal *models.Principal) middleware.Responder {
+		namespace := namespaceOrDefault(params.Namespace)
+		list, err := eventingClient.EventingV1alpha1().Triggers(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return rules.NewGetAllRulesInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		var payload []*models.Rule
+		for _, trigger := range list.Items {
+			if ruleName, ok := trigger.Annotations["kwsk_rule_name"]; ok {
+				payload = append(payload, ruleToModel(ruleName, trigger.Name, &trigger))
+			}
+		}
+		return rules.NewGetAllRulesOK().WithPayload(payload)
+	}
+}
+
+// findTriggerByEventType looks up the Trigger whose Filter matches
+// eventType, the same ce-type header Knative preserves end to end from
+// the original published event through to the Subscriber's delivery.
+// Only Triggers with a kwsk_rule_action annotation (i.e. ones a Rule
+// has been attached to) are considered.
+func findTriggerByEventType(eventingClient *eventing.Clientset, namespace string, eventType string) (*eventingv1alpha1.Trigger, error) {
+	list, err := eventingClient.EventingV1alpha1().Triggers(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, trigger := range list.Items {
+		if trigger.Annotations["kwsk_rule_action"] == "" {
+			continue
+		}
+		if trigger.Spec.Filter != nil && trigger.Spec.Filter.SourceAndType != nil && trigger.Spec.Filter.SourceAndType.Type == eventType {
+			return &trigger, nil
+		}
+	}
+	return nil, errors.NewNotFound(eventingv1alpha1.Resource("triggers"), eventType)
+}
+
+// RuleSubscriberHandler returns the http.Handler that Knative Eventing
+// delivers a Rule's CloudEvents to, per the Subscriber updateRuleFunc
+// points at --rule-subscriber-host. Unlike the handlers configureRules
+// wires up, this isn't a swagger operation -- it isn't part of kwsk's
+// own API -- so whatever bootstraps kwsk's HTTP server must mount it
+// at a path matching ruleSubscriberHost itself.
+//
+// It identifies which Rule fired from the inbound event's ce-type
+// header (Knative preserves CloudEvent attributes end to end from
+// publish through Broker filtering to Subscriber delivery), resolves
+// that Rule's action, and dispatches exactly the same init/run
+// protocol and activation bookkeeping invokeActionFunc uses for a
+// direct invocation.
+func RuleSubscriberHandler(knativeClient *knative.Clientset, eventingClient *eventing.Clientset, store activation.Store, actionCache *cache.ActionCache, initTracker *initstate.Tracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eventType := r.Header.Get("ce-type")
+		if eventType == "" {
+			http.Error(w, "missing ce-type header", http.StatusBadRequest)
+			return
+		}
+		namespace := namespaceOrDefault("_")
+
+		trigger, err := findTriggerByEventType(eventingClient, namespace, eventType)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		actionName := sanitizeActionName(trigger.Annotations["kwsk_rule_action"])
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		actionParams := interface{}(map[string]string{})
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &actionParams); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		route, config, err := resolveActionRouteAndConfig(knativeClient, actionCache, namespace, actionName)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		act := &activation.Activation{
+			ID:        uuid.New().String(),
+			Name:      config.Name,
+			Namespace: namespace,
+			Start:     time.Now(),
+			Status:    activation.StatusRunning,
+		}
+		if err := store.Create(act); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Rules fire the same way Triggers do: fire-and-forget, with
+		// the activation record as the only way to observe the
+		// outcome afterward.
+		go dispatchInvocation(knativeClient, actionCache, initTracker, store, namespace, config, route.Status.Domain, actionParams, act)
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,245 @@
+package restapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	middleware "github.com/go-openapi/runtime/middleware"
+	uuid "github.com/google/uuid"
+
+	models "github.com/projectodd/kwsk/models"
+	"github.com/projectodd/kwsk/restapi/operations"
+	"github.com/projectodd/kwsk/restapi/operations/triggers"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eventingv1alpha1 "github.com/knative/eventing/pkg/apis/eventing/v1alpha1"
+	eventing "github.com/knative/eventing/pkg/client/clientset/versioned"
+
+	knative "github.com/knative/serving/pkg/client/clientset/versioned"
+)
+
+// defaultBrokerName is the Broker kwsk provisions (if one doesn't
+// already exist) in every namespace that has at least one Trigger.
+const defaultBrokerName = "default"
+
+// triggerEventType is the CloudEvents "type" attribute kwsk filters on
+// to route events at the namespace Broker to the Trigger named name.
+// Using the sanitized trigger name as the event type keeps the mapping
+// from OpenWhisk Trigger to Knative eventing Trigger one-to-one.
+func triggerEventType(name string) string {
+	return "kwsk.trigger." + sanitizeActionName(name)
+}
+
+func configureTriggers(api *operations.KwskAPI, knativeClient *knative.Clientset, eventingClient *eventing.Clientset) {
+	api.TriggersDeleteTriggerHandler = triggers.DeleteTriggerHandlerFunc(deleteTriggerFunc(eventingClient))
+
+	api.TriggersGetTriggerByNameHandler = triggers.GetTriggerByNameHandlerFunc(getTriggerByNameFunc(eventingClient))
+
+	api.TriggersGetAllTriggersHandler = triggers.GetAllTriggersHandlerFunc(getAllTriggersFunc(eventingClient))
+
+	api.TriggersUpdateTriggerHandler = triggers.UpdateTriggerHandlerFunc(updateTriggerFunc(eventingClient))
+
+	api.TriggersFireTriggerHandler = triggers.FireTriggerHandlerFunc(fireTriggerFunc(eventingClient))
+}
+
+func ensureDefaultBroker(eventingClient *eventing.Clientset, namespace string) error {
+	_, err := eventingClient.EventingV1alpha1().Brokers(namespace).Get(defaultBrokerName, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	broker := &eventingv1alpha1.Broker{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      defaultBrokerName,
+			Namespace: namespace,
+		},
+	}
+	_, err = eventingClient.EventingV1alpha1().Brokers(namespace).Create(broker)
+	if err != nil && errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func updateTriggerFunc(eventingClient *eventing.Clientset) triggers.UpdateTriggerHandlerFunc {
+	return func(params triggers.UpdateTriggerParams, principal *models.Principal) middleware.Responder {
+		name := params.TriggerName
+		triggerName := sanitizeActionName(name)
+		namespace := namespaceOrDefault(params.Namespace)
+
+		if err := ensureDefaultBroker(eventingClient, namespace); err != nil {
+			return triggers.NewUpdateTriggerInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		trigger := &eventingv1alpha1.Trigger{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      triggerName,
+				Namespace: namespace,
+				Annotations: map[string]string{
+					"kwsk_trigger_name": name,
+				},
+			},
+			Spec: eventingv1alpha1.TriggerSpec{
+				Broker: defaultBrokerName,
+				Filter: &eventingv1alpha1.TriggerFilter{
+					SourceAndType: &eventingv1alpha1.TriggerFilterSourceAndType{
+						Type: triggerEventType(name),
+					},
+				},
+			},
+		}
+
+		_, err := eventingClient.EventingV1alpha1().Triggers(namespace).Create(trigger)
+		if err != nil {
+			log.Printf("error creating trigger: %v", err)
+			return triggers.NewUpdateTriggerInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		result, err := getTriggerByName(eventingClient, name, namespace)
+		if err != nil {
+			return triggers.NewUpdateTriggerInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		return triggers.NewUpdateTriggerOK().WithPayload(result)
+	}
+}
+
+func deleteTriggerFunc(eventingClient *eventing.Clientset) triggers.DeleteTriggerHandlerFunc {
+	return func(params triggers.DeleteTriggerParams, principal *models.Principal) middleware.Responder {
+		triggerName := sanitizeActionName(params.TriggerName)
+		namespace := namespaceOrDefault(params.Namespace)
+
+		err := eventingClient.EventingV1alpha1().Triggers(namespace).Delete(triggerName, &metav1.DeleteOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return triggers.NewDeleteTriggerNotFound().WithPayload(errorMessageFromErr(err))
+			}
+			return triggers.NewDeleteTriggerInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		return triggers.NewDeleteTriggerOK()
+	}
+}
+
+func triggerToModel(trigger *eventingv1alpha1.Trigger) *models.Trigger {
+	name := trigger.Annotations["kwsk_trigger_name"]
+	return &models.Trigger{
+		Name:      &name,
+		Namespace: &trigger.Namespace,
+	}
+}
+
+func getTriggerByName(eventingClient *eventing.Clientset, name string, namespace string) (*models.Trigger, error) {
+	triggerName := sanitizeActionName(name)
+	namespace = namespaceOrDefault(namespace)
+	trigger, err := eventingClient.EventingV1alpha1().Triggers(namespace).Get(triggerName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return triggerToModel(trigger), nil
+}
+
+func getTriggerByNameFunc(eventingClient *eventing.Clientset) triggers.GetTriggerByNameHandlerFunc {
+	return func(params triggers.GetTriggerByNameParams, principal *models.Principal) middleware.Responder {
+		trigger, err := getTriggerByName(eventingClient, params.TriggerName, params.Namespace)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return triggers.NewGetTriggerByNameNotFound().WithPayload(errorMessageFromErr(err))
+			}
+			return triggers.NewGetTriggerByNameInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		return triggers.NewGetTriggerByNameOK().WithPayload(trigger)
+	}
+}
+
+func getAllTriggersFunc(eventingClient *eventing.Clientset) triggers.GetAllTriggersHandlerFunc {
+	return func(params triggers.GetAllTriggersParams, principal *models.Principal) middleware.Responder {
+		namespace := namespaceOrDefault(params.Namespace)
+		list, err := eventingClient.EventingV1alpha1().Triggers(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return triggers.NewGetAllTriggersInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+		payload := make([]*models.Trigger, len(list.Items))
+		for i, trigger := range list.Items {
+			payload[i] = triggerToModel(&trigger)
+		}
+		return triggers.NewGetAllTriggersOK().WithPayload(payload)
+	}
+}
+
+// fireTriggerFunc implements the OpenWhisk "fire a trigger" call
+// (POST /triggers/{name}) by publishing a CloudEvent of the trigger's
+// event type directly to the namespace Broker, exactly as an external
+// event source would. Knative takes care of delivering it to whichever
+// Rules have subscribed an action to this Trigger.
+func fireTriggerFunc(eventingClient *eventing.Clientset) triggers.FireTriggerHandlerFunc {
+	return func(params triggers.FireTriggerParams, principal *models.Principal) middleware.Responder {
+		namespace := namespaceOrDefault(params.Namespace)
+
+		payload := getTriggerPayload(params)
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return triggers.NewFireTriggerInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		err = publishCloudEvent(namespace, defaultBrokerName, triggerEventType(params.TriggerName), body)
+		if err != nil {
+			return triggers.NewFireTriggerInternalServerError().WithPayload(errorMessageFromErr(err))
+		}
+
+		activationId := newActivationID()
+		return triggers.NewFireTriggerOK().WithPayload(&models.ActivationID{ActivationID: &activationId})
+	}
+}
+
+func getTriggerPayload(params triggers.FireTriggerParams) interface{} {
+	if params.Payload == nil {
+		return map[string]string{}
+	}
+	return params.Payload
+}
+
+// brokerIngressHost returns the in-cluster hostname of the Broker's
+// ingress, which is where CloudEvents intended for the Broker's
+// Triggers are published.
+func brokerIngressHost(namespace string, brokerName string) string {
+	return fmt.Sprintf("%s-broker.%s.svc.cluster.local", brokerName, namespace)
+}
+
+// publishCloudEvent POSTs body to the named Broker's ingress using
+// CloudEvents binary content mode, the same way an external event
+// source would publish into the Broker.
+func publishCloudEvent(namespace string, brokerName string, eventType string, body []byte) error {
+	url := fmt.Sprintf("http://%s/", brokerIngressHost(namespace, brokerName))
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("ce-specversion", "0.2")
+	req.Header.Set("ce-type", eventType)
+	req.Header.Set("ce-source", "kwsk")
+	req.Header.Set("ce-id", newActivationID())
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("publishing event to broker %s: status %d", url, res.StatusCode)
+	}
+	return nil
+}
+
+func newActivationID() string {
+	return uuid.New().String()
+}
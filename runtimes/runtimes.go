@@ -0,0 +1,155 @@
+// Package runtimes maintains the mapping from OpenWhisk action Kind
+// strings (e.g. "nodejs:8", "wasm:1") to the container image and
+// init/run message shapes used to invoke them.
+package runtimes
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultImage is used for actions whose Kind has no registered Runtime.
+const DefaultImage = "openwhisk/action-nodejs-v8"
+
+// MessageShape describes the field names a runtime expects in its init
+// and run HTTP bodies. A zero value falls back to the OpenWhisk-standard
+// "main"/"code"/"value" field names.
+type MessageShape struct {
+	MainField  string `json:"mainField,omitempty" yaml:"mainField,omitempty"`
+	CodeField  string `json:"codeField,omitempty" yaml:"codeField,omitempty"`
+	ValueField string `json:"valueField,omitempty" yaml:"valueField,omitempty"`
+}
+
+func (s MessageShape) mainField() string {
+	if s.MainField == "" {
+		return "main"
+	}
+	return s.MainField
+}
+
+func (s MessageShape) codeField() string {
+	if s.CodeField == "" {
+		return "code"
+	}
+	return s.CodeField
+}
+
+func (s MessageShape) valueField() string {
+	if s.ValueField == "" {
+		return "value"
+	}
+	return s.ValueField
+}
+
+// Runtime is the registered image and optional message shapes for a
+// single action Kind.
+type Runtime struct {
+	Image     string       `json:"image" yaml:"image"`
+	InitShape MessageShape `json:"initShape,omitempty" yaml:"initShape,omitempty"`
+	RunShape  MessageShape `json:"runShape,omitempty" yaml:"runShape,omitempty"`
+}
+
+var registry = map[string]Runtime{
+	"nodejs:8": {Image: "openwhisk/action-nodejs-v8"},
+	"python:3": {Image: "openwhisk/python3action"},
+	"go:1.11":  {Image: "openwhisk/action-golang-v1.11"},
+
+	// wasm:1 carries a compiled WebAssembly module through the same
+	// kwsk_action_code annotation path as the other kinds; the image is
+	// a shim capable of loading and running arbitrary Wasm modules.
+	"wasm:1": {Image: "runwasi/wasmedge-shim"},
+}
+
+var manifestPath = flag.String("runtimes", "", "path to a YAML or JSON file registering additional kind-to-image runtime mappings")
+
+// Register adds rt to the registry under kind, overriding any existing
+// entry for that kind.
+func Register(kind string, rt Runtime) {
+	registry[kind] = rt
+}
+
+// Lookup returns the Runtime registered for kind, and whether one was
+// found.
+func Lookup(kind string) (Runtime, bool) {
+	rt, ok := registry[kind]
+	return rt, ok
+}
+
+// ImageFor returns the image registered for kind, falling back to
+// DefaultImage when kind is unknown or empty.
+func ImageFor(kind string) string {
+	if rt, ok := Lookup(kind); ok {
+		return rt.Image
+	}
+	return DefaultImage
+}
+
+// LoadManifest reads a YAML or JSON file of kind-to-Runtime mappings and
+// merges them into the registry, with entries in path taking precedence
+// over any built-in defaults for the same kind. The format is inferred
+// from the file extension, defaulting to YAML.
+func LoadManifest(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading runtimes manifest: %v", err)
+	}
+
+	var manifest map[string]Runtime
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(data, &manifest)
+	} else {
+		err = yaml.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing runtimes manifest %s: %v", path, err)
+	}
+
+	for kind, rt := range manifest {
+		Register(kind, rt)
+	}
+	return nil
+}
+
+// LoadFromFlag loads the manifest named by the --runtimes flag, if one
+// was given on the command line. It is a no-op when the flag is unset.
+func LoadFromFlag() error {
+	if *manifestPath == "" {
+		return nil
+	}
+	return LoadManifest(*manifestPath)
+}
+
+// BuildInitMessage builds the runtime-specific init request body for
+// kind, wrapping code according to the kind's registered InitShape (or
+// the OpenWhisk-standard {value: {main, code}} shape if none is
+// registered).
+func BuildInitMessage(kind string, code string) interface{} {
+	shape := MessageShape{}
+	if rt, ok := Lookup(kind); ok {
+		shape = rt.InitShape
+	}
+	return map[string]interface{}{
+		shape.valueField(): map[string]interface{}{
+			shape.mainField(): "main",
+			shape.codeField(): code,
+		},
+	}
+}
+
+// BuildRunMessage builds the runtime-specific run request body for
+// kind, wrapping params according to the kind's registered RunShape (or
+// the OpenWhisk-standard {value: ...} shape if none is registered).
+func BuildRunMessage(kind string, params interface{}) interface{} {
+	shape := MessageShape{}
+	if rt, ok := Lookup(kind); ok {
+		shape = rt.RunShape
+	}
+	return map[string]interface{}{
+		shape.valueField(): params,
+	}
+}
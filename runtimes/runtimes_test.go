@@ -0,0 +1,126 @@
+package runtimes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImageFor(t *testing.T) {
+	cases := []struct {
+		kind string
+		want string
+	}{
+		{"nodejs:8", "openwhisk/action-nodejs-v8"},
+		{"python:3", "openwhisk/python3action"},
+		{"unknown:1", DefaultImage},
+		{"", DefaultImage},
+	}
+	for _, c := range cases {
+		if got := ImageFor(c.kind); got != c.want {
+			t.Errorf("ImageFor(%q) = %q, want %q", c.kind, got, c.want)
+		}
+	}
+}
+
+func TestBuildInitMessageDefaultShape(t *testing.T) {
+	got := BuildInitMessage("nodejs:8", "console.log('hi')")
+	want := map[string]interface{}{
+		"value": map[string]interface{}{
+			"main": "main",
+			"code": "console.log('hi')",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildInitMessage() = %#v, want %#v", got, want)
+	}
+}
+
+func TestBuildInitMessageCustomShape(t *testing.T) {
+	Register("custom:1", Runtime{
+		Image:     "example/custom",
+		InitShape: MessageShape{MainField: "entrypoint", CodeField: "source", ValueField: "init"},
+	})
+	defer delete(registry, "custom:1")
+
+	got := BuildInitMessage("custom:1", "package main")
+	want := map[string]interface{}{
+		"init": map[string]interface{}{
+			"entrypoint": "main",
+			"source":     "package main",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildInitMessage() = %#v, want %#v", got, want)
+	}
+}
+
+func TestBuildRunMessageDefaultShape(t *testing.T) {
+	params := map[string]string{"foo": "bar"}
+	got := BuildRunMessage("nodejs:8", params)
+	want := map[string]interface{}{"value": params}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildRunMessage() = %#v, want %#v", got, want)
+	}
+}
+
+func TestBuildRunMessageCustomShape(t *testing.T) {
+	Register("custom:2", Runtime{
+		Image:    "example/custom",
+		RunShape: MessageShape{ValueField: "input"},
+	})
+	defer delete(registry, "custom:2")
+
+	params := map[string]string{"foo": "bar"}
+	got := BuildRunMessage("custom:2", params)
+	want := map[string]interface{}{"input": params}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildRunMessage() = %#v, want %#v", got, want)
+	}
+}
+
+func TestLoadManifestYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runtimes-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "manifest.yaml")
+	contents := "ruby:2.7:\n  image: example/ruby-action\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(registry, "ruby:2.7")
+
+	if err := LoadManifest(path); err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if got := ImageFor("ruby:2.7"); got != "example/ruby-action" {
+		t.Errorf("ImageFor(\"ruby:2.7\") = %q, want %q", got, "example/ruby-action")
+	}
+}
+
+func TestLoadManifestJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runtimes-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "manifest.json")
+	contents := `{"java:11": {"image": "example/java-action"}}`
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(registry, "java:11")
+
+	if err := LoadManifest(path); err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if got := ImageFor("java:11"); got != "example/java-action" {
+		t.Errorf("ImageFor(\"java:11\") = %q, want %q", got, "example/java-action")
+	}
+}